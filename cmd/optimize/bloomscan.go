@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	scanStateMagic   uint32 = 0x6d6f6c65 // "mole"
+	scanStateVersion uint32 = 1
+)
+
+const (
+	scanStateNumCycles    = 4
+	scanStateExpectedN    = 100_000
+	scanStateFalsePosRate = 0.01
+)
+
+// bloomFilter is a fixed-size bit array with k independent hash probes,
+// sized for an expected entry count and target false-positive rate.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(expectedN int, falsePosRate float64) *bloomFilter {
+	m := optimalBloomBits(expectedN, falsePosRate)
+	k := optimalBloomHashes(m, expectedN)
+	words := (m + 63) / 64
+
+	return &bloomFilter{
+		bits: make([]uint64, words),
+		m:    m,
+		k:    k,
+	}
+}
+
+func optimalBloomBits(n int, p float64) uint64 {
+	if n <= 0 {
+		n = 1
+	}
+	m := -1 * float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)
+	return uint64(math.Ceil(m))
+}
+
+func optimalBloomHashes(m uint64, n int) uint64 {
+	if n <= 0 {
+		n = 1
+	}
+	k := (float64(m) / float64(n)) * math.Ln2
+	if k < 1 {
+		k = 1
+	}
+	return uint64(math.Round(k))
+}
+
+// probe returns the i'th bit index for key using double hashing over two
+// independent FNV hashes, which is standard practice for bloom filters and
+// avoids computing k fully independent hash functions.
+func (b *bloomFilter) probe(key string, i uint64) uint64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	sum1 := h1.Sum64()
+
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	sum2 := h2.Sum64()
+
+	return (sum1 + i*sum2) % b.m
+}
+
+func (b *bloomFilter) Add(key string) {
+	for i := uint64(0); i < b.k; i++ {
+		idx := b.probe(key, i)
+		b.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+func (b *bloomFilter) MightContain(key string) bool {
+	for i := uint64(0); i < b.k; i++ {
+		idx := b.probe(key, i)
+		if b.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) Clear() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
+
+// scanState tracks a rotating set of bloom filters, one per scan cycle. A
+// path only needs a full rescan if it hasn't been seen by any filter in the
+// rotation (guaranteeing a full rescan at least every scanStateNumCycles
+// runs) or its own mtime has advanced since it was last scanned.
+type scanState struct {
+	mu            sync.Mutex
+	cycle         uint32
+	filters       [scanStateNumCycles]*bloomFilter
+	forceFullScan bool
+	dirty         bool
+	path          string
+}
+
+var (
+	scanStateOnce sync.Once
+	scanStateInst *scanState
+)
+
+func getScanState() *scanState {
+	scanStateOnce.Do(func() {
+		scanStateInst = loadScanState()
+	})
+	return scanStateInst
+}
+
+func scanStatePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "Library", "Application Support", "Mole", "scan-state.bin")
+}
+
+func loadScanState() *scanState {
+	s := &scanState{path: scanStatePath()}
+	for i := range s.filters {
+		s.filters[i] = newBloomFilter(scanStateExpectedN, scanStateFalsePosRate)
+	}
+
+	for _, arg := range os.Args[1:] {
+		if arg == "--force-full-scan" {
+			s.forceFullScan = true
+		}
+	}
+
+	if s.path == "" || s.forceFullScan {
+		return s
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil || len(data) < 12 {
+		return s
+	}
+
+	magic := binary.LittleEndian.Uint32(data[0:4])
+	version := binary.LittleEndian.Uint32(data[4:8])
+	if magic != scanStateMagic || version != scanStateVersion {
+		return s
+	}
+	s.cycle = binary.LittleEndian.Uint32(data[8:12])
+
+	offset := 12
+	for i := range s.filters {
+		if offset+12 > len(data) {
+			return s
+		}
+		m := binary.LittleEndian.Uint64(data[offset : offset+8])
+		k := binary.LittleEndian.Uint32(data[offset+8 : offset+12])
+		offset += 12
+
+		words := int((m + 63) / 64)
+		if offset+words*8 > len(data) {
+			return s
+		}
+
+		bits := make([]uint64, words)
+		for w := 0; w < words; w++ {
+			bits[w] = binary.LittleEndian.Uint64(data[offset+w*8 : offset+w*8+8])
+		}
+		offset += words * 8
+
+		s.filters[i] = &bloomFilter{bits: bits, m: m, k: uint64(k)}
+	}
+
+	return s
+}
+
+func (s *scanState) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty || s.path == "" {
+		return
+	}
+
+	var buf []byte
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header[0:4], scanStateMagic)
+	binary.LittleEndian.PutUint32(header[4:8], scanStateVersion)
+	binary.LittleEndian.PutUint32(header[8:12], s.cycle)
+	buf = append(buf, header...)
+
+	for _, f := range s.filters {
+		meta := make([]byte, 12)
+		binary.LittleEndian.PutUint64(meta[0:8], f.m)
+		binary.LittleEndian.PutUint32(meta[8:12], uint32(f.k))
+		buf = append(buf, meta...)
+
+		for _, word := range f.bits {
+			wordBytes := make([]byte, 8)
+			binary.LittleEndian.PutUint64(wordBytes, word)
+			buf = append(buf, wordBytes...)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(s.path, buf, 0o644)
+}
+
+// needsRescan reports whether path must be fully rescanned: it hasn't been
+// seen in the current rotation, or its own mtime advanced since last time.
+func (s *scanState) needsRescan(path string, mtimeChanged bool) bool {
+	if s.forceFullScan || mtimeChanged {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.filters {
+		if f.MightContain(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// markScanned records path as reviewed in the current cycle's filter.
+func (s *scanState) markScanned(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filters[s.cycle%scanStateNumCycles].Add(path)
+	s.dirty = true
+}
+
+// advanceCycle moves to the next rotation slot and clears its filter, which
+// is what guarantees every path gets a full rescan at least once every
+// scanStateNumCycles runs.
+func (s *scanState) advanceCycle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cycle = (s.cycle + 1) % scanStateNumCycles
+	s.filters[s.cycle].Clear()
+	s.dirty = true
+}
+
+// scanRootWithBloomGate recomputes root's size by applying the bloom/rotation
+// fast path at the granularity the incremental-scan feature actually targets:
+// root's own immediate subdirectories (e.g. each hashed DerivedData bucket,
+// each app's folder under Caches). A subdirectory whose mtime hasn't changed
+// and that the rotation hasn't flagged due for a mandatory rescan reuses its
+// last known size outright, skipping a full walk of that subtree entirely;
+// everything else is rescanned concurrently via scanTreeKB.
+func scanRootWithBloomGate(root string) int64 {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0
+	}
+
+	scanner := getDirScanner()
+	state := getScanState()
+
+	outerWorkers := adaptiveWorkerCount()
+	if outerWorkers > 4 {
+		outerWorkers = 4
+	}
+	sem := make(chan struct{}, outerWorkers)
+
+	var wg sync.WaitGroup
+	var totalBytes int64
+
+	for _, entry := range entries {
+		full := filepath.Join(root, entry.Name())
+
+		if !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				atomic.AddInt64(&totalBytes, info.Size())
+			}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(dir string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			atomic.AddInt64(&totalBytes, scanSubdirBytesCached(dir, scanner, state))
+		}(full)
+	}
+
+	wg.Wait()
+	return totalBytes / 1024
+}
+
+// scanSubdirBytesCached returns dir's apparent size in bytes, reusing the
+// cached value when the bloom/rotation state says dir doesn't need a
+// rescan, and fully rescanning (then caching the result) otherwise.
+func scanSubdirBytesCached(dir string, scanner *dirScanner, state *scanState) int64 {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0
+	}
+	mtime := info.ModTime().Unix()
+
+	cached, ok := scanner.getEntry(dir)
+	changed := !ok || cached.ModTime != mtime
+
+	if !state.needsRescan(dir, changed) {
+		return cached.SizeKB * 1024
+	}
+
+	sizeKB := scanTreeKB(dir)
+	scanner.setEntry(dir, dirCacheEntry{
+		SizeKB:    sizeKB,
+		ModTime:   mtime,
+		ScannedAt: time.Now().Unix(),
+	})
+	state.markScanned(dir)
+
+	return sizeKB * 1024
+}
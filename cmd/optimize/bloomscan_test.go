@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterAddAndContain(t *testing.T) {
+	b := newBloomFilter(1000, 0.01)
+
+	keys := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		keys = append(keys, fmt.Sprintf("/Users/x/Library/Caches/bucket-%d", i))
+	}
+	for _, k := range keys {
+		b.Add(k)
+	}
+
+	for _, k := range keys {
+		if !b.MightContain(k) {
+			t.Fatalf("MightContain(%q) = false, want true after Add", k)
+		}
+	}
+}
+
+func TestBloomFilterClear(t *testing.T) {
+	b := newBloomFilter(1000, 0.01)
+	b.Add("/some/path")
+	if !b.MightContain("/some/path") {
+		t.Fatal("expected path to be present before Clear")
+	}
+
+	b.Clear()
+	if b.MightContain("/some/path") {
+		t.Fatal("expected path to be absent after Clear")
+	}
+}
+
+func TestOptimalBloomBitsAndHashesArePositive(t *testing.T) {
+	m := optimalBloomBits(scanStateExpectedN, scanStateFalsePosRate)
+	if m == 0 {
+		t.Fatal("optimalBloomBits returned 0")
+	}
+
+	k := optimalBloomHashes(m, scanStateExpectedN)
+	if k == 0 {
+		t.Fatal("optimalBloomHashes returned 0")
+	}
+}
+
+func TestOptimalBloomBitsHandlesZeroN(t *testing.T) {
+	// n <= 0 must not panic or divide by zero; it should fall back to n=1.
+	if m := optimalBloomBits(0, 0.01); m == 0 {
+		t.Fatal("optimalBloomBits(0, ...) returned 0")
+	}
+	if k := optimalBloomHashes(64, 0); k == 0 {
+		t.Fatal("optimalBloomHashes(64, 0) returned 0")
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// faultClass is one signal of system distress we look for in the unified
+// log, correlated where possible with crash reports on disk.
+type faultClass struct {
+	key            string
+	name           string
+	predicate      string // substring matched against eventMessage, lowercased
+	correlateCrash bool
+}
+
+var faultClasses = []faultClass{
+	{key: "kernel_panic", name: "Kernel Panics", predicate: "panic("},
+	{key: "wakeups", name: "Excessive Wakeups", predicate: "wakeups"},
+	{key: "hangtracer", name: "Hang Tracer Events", predicate: "hangtracer", correlateCrash: true},
+	{key: "spindump", name: "Spindump Reports", predicate: "spindump", correlateCrash: true},
+	{key: "reportcrash", name: "Crash Reports", predicate: "reportcrash", correlateCrash: true},
+}
+
+type logEvent struct {
+	EventMessage string `json:"eventMessage"`
+}
+
+// collectDiagnosticsItems queries the unified log for the last 24h for
+// signals of system distress and correlates them with crash reports under
+// DiagnosticReports, producing one OptimizationItem per detected fault class
+// plus, if there's anything to reclaim, one item offering to actually delete
+// those reports, instead of the old blanket "Diagnostics Cleanup" log purge.
+func collectDiagnosticsItems() []OptimizationItem {
+	events, _ := queryUnifiedLog()
+	topProcess := topOffendingProcess()
+
+	var items []OptimizationItem
+	for _, fc := range faultClasses {
+		count := 0
+		for _, ev := range events {
+			if strings.Contains(strings.ToLower(ev.EventMessage), fc.predicate) {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		desc := fmt.Sprintf("%d events in the last 24h", count)
+		if fc.correlateCrash && topProcess != "" {
+			desc = fmt.Sprintf("%s (top offender: %s)", desc, topProcess)
+		}
+
+		items = append(items, OptimizationItem{
+			Category:    "diagnostics",
+			Name:        fc.name,
+			Description: desc,
+			Action:      "collect_diagnostics",
+			Safe:        true,
+		})
+	}
+
+	if item := buildLogPurgeItem(); item != nil {
+		items = append(items, *item)
+	}
+	return items
+}
+
+// buildLogPurgeItem surfaces the "log_cleanup" action (a dirDeleteRunner,
+// snapshotted and journaled like the other destructive cleanups) so the
+// triage items above aren't the only way diagnostics are surfaced — without
+// this, nothing ever points a user at the space DiagnosticReports can
+// actually reclaim; collect_diagnostics only archives, it never deletes.
+func buildLogPurgeItem() *OptimizationItem {
+	var totalKB int64
+	for _, dir := range diagnosticReportDirs() {
+		totalKB += dirSizeKB(dir)
+	}
+	if totalKB == 0 {
+		return nil
+	}
+
+	return &OptimizationItem{
+		Category:    "diagnostics",
+		Name:        "Diagnostic Report Cleanup",
+		Description: fmt.Sprintf("Delete %s of crash/diagnostic reports (archive first via Collect Diagnostics)", formatSizeFromKB(totalKB)),
+		Action:      "log_cleanup",
+		Safe:        false,
+	}
+}
+
+// diagnosticsLogPredicate matches the unified log signals we triage:
+// kernel panics, excessive wakeups, hangtracer/spindump reports, and
+// ReportCrash invocations. Shared between queryUnifiedLog (triage) and
+// fetchFilteredLogArchive (the collect_diagnostics bundle).
+const diagnosticsLogPredicate = `eventMessage contains "panic(" OR eventMessage contains "wakeups" OR ` +
+	`eventMessage contains "hangtracer" OR eventMessage contains "spindump" OR ` +
+	`eventMessage contains "ReportCrash"`
+
+// queryUnifiedLog runs `log show` over the last 24h filtered to the fault
+// classes we care about, parsing its ndjson output one event per line.
+func queryUnifiedLog() ([]logEvent, error) {
+	output, err := fetchFilteredLogArchive()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []logEvent
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var ev logEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// fetchFilteredLogArchive runs `log show` over the last 24h filtered to
+// diagnosticsLogPredicate and returns the raw ndjson output, used both for
+// triage (queryUnifiedLog) and as the log archive component of the
+// collect_diagnostics bundle.
+func fetchFilteredLogArchive() ([]byte, error) {
+	cmd := exec.Command("log", "show", "--style", "ndjson", "--last", "24h", "--predicate", diagnosticsLogPredicate)
+	return cmd.Output()
+}
+
+func diagnosticReportDirs() []string {
+	dirs := []string{"/Library/Logs/DiagnosticReports"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Library", "Logs", "DiagnosticReports"))
+	}
+	return dirs
+}
+
+// topOffendingProcess tallies the procName field across .ips crash reports
+// (JSON since macOS 12: a header line followed by a JSON body) and returns
+// whichever process crashed most often.
+func topOffendingProcess() string {
+	counts := make(map[string]int)
+
+	for _, dir := range diagnosticReportDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".ips") {
+				continue
+			}
+			if name, ok := parseIPSProcName(filepath.Join(dir, entry.Name())); ok {
+				counts[name]++
+			}
+		}
+	}
+
+	best, bestCount := "", 0
+	for name, count := range counts {
+		if count > bestCount {
+			best, bestCount = name, count
+		}
+	}
+	return best
+}
+
+// parseIPSProcName extracts the crashing process's name from an .ips file.
+// The format is a JSON header line followed by a JSON crash report body.
+func parseIPSProcName(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	parts := bytes.SplitN(data, []byte("\n"), 2)
+	if len(parts) < 2 {
+		return "", false
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(parts[1], &body); err != nil {
+		return "", false
+	}
+
+	name, ok := body["procName"].(string)
+	return name, ok && name != ""
+}
+
+// diagnosticsCollectRunner implements the "collect_diagnostics" action: it
+// bundles DiagnosticReports crash files into a tar.gz on the Desktop for
+// support handoff. It only reads files, so it needs no snapshot or journal
+// entry and cannot be rolled back.
+type diagnosticsCollectRunner struct{}
+
+func (r *diagnosticsCollectRunner) Preview(ctx context.Context) ([]FSChange, error) {
+	var changes []FSChange
+	for _, dir := range diagnosticReportDirs() {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			changes = append(changes, FSChange{Path: path, SizeBytes: info.Size(), Op: "archive"})
+			return nil
+		})
+	}
+	return changes, nil
+}
+
+func (r *diagnosticsCollectRunner) Execute(ctx context.Context, opts ExecuteOptions) (<-chan Progress, error) {
+	changes, err := r.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		ch := make(chan Progress, len(changes))
+		for _, c := range changes {
+			ch <- Progress{Path: c.Path}
+		}
+		close(ch)
+		return ch, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	archivePath := filepath.Join(home, "Desktop", fmt.Sprintf("mole-diagnostics-%d.tar.gz", time.Now().Unix()))
+
+	// The bundle is the crash reports plus a filtered unified-log archive
+	// covering the same window the triage items in collectDiagnosticsItems
+	// were built from; a missing log archive shouldn't block the crash
+	// reports from still being collected.
+	extra := make(map[string][]byte)
+	if logData, err := fetchFilteredLogArchive(); err == nil {
+		extra["mole-log-archive.ndjson"] = logData
+	}
+
+	ch := make(chan Progress)
+	go func() {
+		defer close(ch)
+		if err := writeDiagnosticsArchive(archivePath, changes, extra); err != nil {
+			ch <- Progress{Error: err.Error()}
+			ch <- Progress{Done: true}
+			return
+		}
+		for _, c := range changes {
+			ch <- Progress{Path: c.Path}
+		}
+		for name := range extra {
+			ch <- Progress{Path: name}
+		}
+		ch <- Progress{Path: archivePath, Done: true}
+	}()
+	return ch, nil
+}
+
+func (r *diagnosticsCollectRunner) Rollback(ctx context.Context, snapshotID string) error {
+	return fmt.Errorf("collect_diagnostics only reads files and has nothing to roll back")
+}
+
+// writeDiagnosticsArchive bundles changes (files read from disk) and extra
+// (in-memory entries, e.g. the filtered log archive) into a single tar.gz.
+// tw/gz are explicitly closed (flushing their buffered output) before f is,
+// rather than left to deferred Close calls whose errors would otherwise be
+// discarded — a flush failure (e.g. disk full) must not be reported as a
+// successful archive.
+func writeDiagnosticsArchive(archivePath string, changes []FSChange, extra map[string][]byte) (err error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	defer func() {
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, c := range changes {
+		data, err := os.ReadFile(c.Path)
+		if err != nil {
+			continue
+		}
+
+		hdr := &tar.Header{
+			Name: strings.TrimPrefix(c.Path, "/"),
+			Mode: 0o644,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	for name, data := range extra {
+		hdr := &tar.Header{
+			Name: name,
+			Mode: 0o644,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIPSProcName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crash.ips")
+
+	// Real .ips files are a JSON header line followed by a JSON body line;
+	// only the body carries procName.
+	content := `{"app_name":"Finder","timestamp":"2026-07-25 12:00:00.00"}
+{"procName":"Finder","pid":123}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	name, ok := parseIPSProcName(path)
+	if !ok || name != "Finder" {
+		t.Fatalf("parseIPSProcName() = (%q, %v), want (%q, true)", name, ok, "Finder")
+	}
+}
+
+func TestParseIPSProcNameMissingProcName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crash.ips")
+
+	content := "{\"app_name\":\"Finder\"}\n{\"pid\":123}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := parseIPSProcName(path); ok {
+		t.Fatal("parseIPSProcName() ok = true for a body with no procName")
+	}
+}
+
+func TestParseIPSProcNameMissingFile(t *testing.T) {
+	if _, ok := parseIPSProcName("/nonexistent/crash.ips"); ok {
+		t.Fatal("parseIPSProcName() ok = true for a nonexistent file")
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FSChange describes one filesystem change an action would make, emitted by
+// Preview so a caller (a GUI, or `mole run --dry-run`) can show it before
+// anything touches disk.
+type FSChange struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Op        string `json:"op"`
+}
+
+// Progress is one line of a newline-delimited JSON progress stream emitted
+// while an action executes.
+type Progress struct {
+	Path       string `json:"path,omitempty"`
+	BytesFreed int64  `json:"bytes_freed,omitempty"`
+	Done       bool   `json:"done,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ExecuteOptions controls how an ActionRunner carries out its action.
+type ExecuteOptions struct {
+	DryRun bool
+}
+
+// ActionRunner turns an Action string (previously just a label the caller
+// had to interpret) into something that can be previewed, executed with
+// streamed progress, and rolled back from the local snapshot it took before
+// running.
+type ActionRunner interface {
+	Preview(ctx context.Context) ([]FSChange, error)
+	Execute(ctx context.Context, opts ExecuteOptions) (<-chan Progress, error)
+	Rollback(ctx context.Context, snapshotID string) error
+}
+
+// destructiveActions require an APFS local snapshot and a journal entry
+// before they touch anything.
+var destructiveActions = map[string]bool{
+	"swap_cleanup":        true,
+	"developer_cleanup":   true,
+	"mail_downloads":      true,
+	"saved_state_cleanup": true,
+	"log_cleanup":         true,
+}
+
+// runnerFor resolves an Action string to the ActionRunner that implements it.
+func runnerFor(action string) (ActionRunner, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "developer_cleanup":
+		return &dirDeleteRunner{action: action, roots: []string{
+			filepath.Join(home, "Library", "Developer", "Xcode", "DerivedData"),
+			filepath.Join(home, "Library", "Developer", "Xcode", "Archives"),
+			filepath.Join(home, "Library", "Developer", "Xcode", "iOS DeviceSupport"),
+			filepath.Join(home, "Library", "Developer", "CoreSimulator", "Caches"),
+		}}, nil
+	case "mail_downloads":
+		return &dirDeleteRunner{action: action, roots: []string{
+			filepath.Join(home, "Library", "Mail Downloads"),
+			filepath.Join(home, "Library", "Containers", "com.apple.mail", "Data", "Library", "Mail Downloads"),
+		}}, nil
+	case "saved_state_cleanup":
+		return &dirDeleteRunner{action: action, roots: []string{
+			filepath.Join(home, "Library", "Saved Application State"),
+		}}, nil
+	case "log_cleanup":
+		return &dirDeleteRunner{action: action, roots: []string{
+			filepath.Join(home, "Library", "Logs", "DiagnosticReports"),
+			"/Library/Logs/DiagnosticReports",
+		}}, nil
+	case "swap_cleanup":
+		return &swapCleanupRunner{}, nil
+	case "collect_diagnostics":
+		return &diagnosticsCollectRunner{}, nil
+	default:
+		return nil, fmt.Errorf("no runner registered for action %q", action)
+	}
+}
+
+// createLocalSnapshot takes an APFS local snapshot via tmutil and returns its
+// ID, so a destructive action can be undone with `mole rollback`.
+func createLocalSnapshot() (string, error) {
+	output, err := exec.Command("tmutil", "localsnapshot").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// tmutil prints a line like: "Created local snapshot with date: 2026-07-25-120000"
+	idx := strings.LastIndex(string(output), ":")
+	if idx == -1 {
+		return "", fmt.Errorf("could not parse snapshot id from tmutil output: %q", output)
+	}
+	snapshotID := strings.TrimSpace(string(output)[idx+1:])
+	if snapshotID == "" {
+		return "", fmt.Errorf("could not parse snapshot id from tmutil output: %q", output)
+	}
+	return snapshotID, nil
+}
+
+// rootDeviceNode resolves the actual device node backing "/" (e.g.
+// "/dev/disk3s1"), which mount_apfs requires as its device argument — the
+// mount path "/" itself is not a valid device. Converts diskutil's plist
+// output to JSON via plutil, the same approach checkBackgroundTaskItems uses
+// since there's no bplist decoder in the standard library.
+func rootDeviceNode() (string, error) {
+	raw, err := exec.Command("diskutil", "info", "-plist", "/").Output()
+	if err != nil {
+		return "", fmt.Errorf("diskutil info /: %w", err)
+	}
+
+	convert := exec.Command("plutil", "-convert", "json", "-o", "-", "-")
+	convert.Stdin = bytes.NewReader(raw)
+	converted, err := convert.Output()
+	if err != nil {
+		return "", fmt.Errorf("converting diskutil plist: %w", err)
+	}
+
+	var info struct {
+		DeviceNode string `json:"DeviceNode"`
+	}
+	if err := json.Unmarshal(converted, &info); err != nil {
+		return "", fmt.Errorf("parsing diskutil output: %w", err)
+	}
+	if info.DeviceNode == "" {
+		return "", fmt.Errorf("diskutil info / had no DeviceNode")
+	}
+	return info.DeviceNode, nil
+}
+
+func mountLocalSnapshot(snapshotID string) (string, error) {
+	device, err := rootDeviceNode()
+	if err != nil {
+		return "", fmt.Errorf("resolving root device node: %w", err)
+	}
+
+	mountPoint := filepath.Join(os.TempDir(), "mole-rollback-"+snapshotID)
+	if err := os.MkdirAll(mountPoint, 0o755); err != nil {
+		return "", err
+	}
+
+	if err := exec.Command("mount_apfs", "-s", snapshotID, device, mountPoint).Run(); err != nil {
+		return "", fmt.Errorf("mounting snapshot %s: %w", snapshotID, err)
+	}
+	return mountPoint, nil
+}
+
+func unmountSnapshot(mountPoint string) {
+	_ = exec.Command("umount", mountPoint).Run()
+	_ = os.Remove(mountPoint)
+}
+
+// copyTree copies every file under src on top of dst, recreating directories
+// as needed. Used by Rollback to restore files out of a mounted snapshot.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
+
+// dirDeleteRunner is an ActionRunner over a fixed set of directory roots
+// whose contents get deleted wholesale: developer caches, mail downloads,
+// saved application state, and diagnostic logs.
+type dirDeleteRunner struct {
+	action string
+	roots  []string
+}
+
+func (r *dirDeleteRunner) Preview(ctx context.Context) ([]FSChange, error) {
+	var changes []FSChange
+	for _, root := range r.roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			changes = append(changes, FSChange{Path: path, SizeBytes: info.Size(), Op: "delete"})
+			return nil
+		})
+	}
+	return changes, nil
+}
+
+func (r *dirDeleteRunner) Execute(ctx context.Context, opts ExecuteOptions) (<-chan Progress, error) {
+	changes, err := r.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		ch := make(chan Progress, len(changes))
+		for _, c := range changes {
+			ch <- Progress{Path: c.Path, BytesFreed: c.SizeBytes}
+		}
+		close(ch)
+		return ch, nil
+	}
+
+	if err := snapshotAndJournal(r.action, changes); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Progress)
+	go func() {
+		defer close(ch)
+		for _, c := range changes {
+			if err := os.RemoveAll(c.Path); err != nil {
+				ch <- Progress{Path: c.Path, Error: err.Error()}
+				continue
+			}
+			ch <- Progress{Path: c.Path, BytesFreed: c.SizeBytes}
+		}
+		ch <- Progress{Done: true}
+	}()
+	return ch, nil
+}
+
+func (r *dirDeleteRunner) Rollback(ctx context.Context, snapshotID string) error {
+	mountPoint, err := mountLocalSnapshot(snapshotID)
+	if err != nil {
+		return err
+	}
+	defer unmountSnapshot(mountPoint)
+
+	for _, root := range r.roots {
+		src := filepath.Join(mountPoint, root)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := copyTree(src, root); err != nil {
+			return fmt.Errorf("restoring %s: %w", root, err)
+		}
+	}
+	return nil
+}
+
+// swapCleanupRunner purges inactive memory rather than deleting swapfiles
+// directly, since the kernel owns and recreates them; Preview reports their
+// current size for visibility only.
+type swapCleanupRunner struct{}
+
+func (r *swapCleanupRunner) Preview(ctx context.Context) ([]FSChange, error) {
+	matches, err := filepath.Glob("/private/var/vm/swapfile*")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FSChange
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil {
+			changes = append(changes, FSChange{Path: m, SizeBytes: info.Size(), Op: "purge"})
+		}
+	}
+	return changes, nil
+}
+
+func (r *swapCleanupRunner) Execute(ctx context.Context, opts ExecuteOptions) (<-chan Progress, error) {
+	changes, err := r.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		ch := make(chan Progress, len(changes))
+		for _, c := range changes {
+			ch <- Progress{Path: c.Path, BytesFreed: c.SizeBytes}
+		}
+		close(ch)
+		return ch, nil
+	}
+
+	if err := snapshotAndJournal("swap_cleanup", changes); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Progress)
+	go func() {
+		defer close(ch)
+		if err := exec.Command("purge").Run(); err != nil {
+			ch <- Progress{Error: err.Error()}
+		} else {
+			for _, c := range changes {
+				ch <- Progress{Path: c.Path, BytesFreed: c.SizeBytes}
+			}
+		}
+		ch <- Progress{Done: true}
+	}()
+	return ch, nil
+}
+
+func (r *swapCleanupRunner) Rollback(ctx context.Context, snapshotID string) error {
+	return fmt.Errorf("swap_cleanup purges memory, not files, and cannot be rolled back")
+}
+
+// snapshotAndJournal takes a local snapshot and records it in the journal
+// before any destructive action touches a path in changes. It consults
+// destructiveActions itself, so it is a no-op for any action not registered
+// there — the map is the single source of truth for which actions get this
+// guarantee, rather than each runner having to remember to ask for it.
+func snapshotAndJournal(action string, changes []FSChange) error {
+	if !destructiveActions[action] {
+		return nil
+	}
+
+	snapshotID, err := createLocalSnapshot()
+	if err != nil {
+		return fmt.Errorf("creating local snapshot: %w", err)
+	}
+
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+
+	return appendJournalEntry(journalEntry{
+		ID:         fmt.Sprintf("%d", time.Now().UnixNano()),
+		Action:     action,
+		SnapshotID: snapshotID,
+		Timestamp:  time.Now().Unix(),
+		Paths:      paths,
+	})
+}
+
+// runAction implements `mole run <action> [--dry-run]`.
+func runAction(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mole run <action> [--dry-run]")
+		os.Exit(1)
+	}
+
+	action := args[0]
+	dryRun := false
+	for _, a := range args[1:] {
+		if a == "--dry-run" {
+			dryRun = true
+		}
+	}
+
+	runner, err := runnerFor(action)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	progress, err := runner.Execute(ctx, ExecuteOptions{DryRun: dryRun})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing %s: %v\n", action, err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for p := range progress {
+		_ = encoder.Encode(p)
+	}
+}
+
+// runRollback implements `mole rollback <journalID>`.
+func runRollback(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mole rollback <journalID>")
+		os.Exit(1)
+	}
+	journalID := args[0]
+
+	entry, err := findJournalEntry(journalID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if entry == nil {
+		fmt.Fprintf(os.Stderr, "Error: no journal entry %q\n", journalID)
+		os.Exit(1)
+	}
+
+	runner, err := runnerFor(entry.Action)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := runner.Rollback(context.Background(), entry.SnapshotID); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rolling back %s: %v\n", journalID, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rolled back %s (snapshot %s)\n", journalID, entry.SnapshotID)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyTreeRestoresFiles exercises the part of Rollback that actually
+// restores data once a snapshot is mounted: copyTree copying a file tree back
+// on top of the live filesystem. Mounting a real APFS snapshot via
+// mount_apfs/diskutil requires macOS and isn't exercisable here, but this is
+// the mechanism that determines whether a rollback actually restores a file.
+func TestCopyTreeRestoresFiles(t *testing.T) {
+	snapshotRoot := t.TempDir()
+	liveRoot := t.TempDir()
+
+	nested := filepath.Join(snapshotRoot, "sub")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(snapshotRoot, "kept.txt"), []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "deep.txt"), []byte("deep original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate the live tree having been mutated after the snapshot: one
+	// file was deleted, the other was overwritten.
+	if err := os.WriteFile(filepath.Join(liveRoot, "kept.txt"), []byte("mutated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTree(snapshotRoot, liveRoot); err != nil {
+		t.Fatalf("copyTree() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(liveRoot, "kept.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "original" {
+		t.Errorf("kept.txt = %q, want %q", got, "original")
+	}
+
+	gotDeep, err := os.ReadFile(filepath.Join(liveRoot, "sub", "deep.txt"))
+	if err != nil {
+		t.Fatalf("deep.txt was not restored: %v", err)
+	}
+	if string(gotDeep) != "deep original" {
+		t.Errorf("deep.txt = %q, want %q", gotDeep, "deep original")
+	}
+}
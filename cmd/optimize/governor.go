@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultMaxMemMB int64 = 256
+
+// pressureMode selects how aggressively the scanner throttles itself under
+// load; controlled via MOLE_PRESSURE_MODE.
+const (
+	pressureStrict   = "strict"
+	pressureAdaptive = "adaptive"
+	pressureOff      = "off"
+)
+
+// RuntimeLimits records the self-imposed resource limits mole chose for this
+// run, surfaced in the JSON output under "runtime" for observability.
+type RuntimeLimits struct {
+	MaxMemMB     int64  `json:"max_mem_mb"`
+	MaxProcs     int    `json:"max_procs"`
+	PressureMode string `json:"pressure_mode"`
+}
+
+var (
+	runtimeLimitsMu sync.Mutex
+	runtimeLimits   RuntimeLimits
+)
+
+// SetSelfLimits caps mole's own memory and CPU footprint so the optimizer
+// never itself becomes the cause of user-visible slowdown, analogous to how
+// container runtimes auto-tune GOMEMLIMIT from a cgroup memory limit.
+// MOLE_MAX_MEM (MiB), MOLE_MAX_PROCS, and MOLE_PRESSURE_MODE
+// (strict|adaptive|off) override the defaults.
+func SetSelfLimits() RuntimeLimits {
+	maxMemMB := defaultMaxMemMB
+	if v := os.Getenv("MOLE_MAX_MEM"); v != "" {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed > 0 {
+			maxMemMB = parsed
+		}
+	}
+
+	maxProcs := runtime.NumCPU() / 2
+	if maxProcs < 1 {
+		maxProcs = 1
+	}
+	if v := os.Getenv("MOLE_MAX_PROCS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			maxProcs = parsed
+		}
+	}
+
+	mode := pressureAdaptive
+	switch os.Getenv("MOLE_PRESSURE_MODE") {
+	case pressureStrict:
+		mode = pressureStrict
+	case pressureOff:
+		mode = pressureOff
+	}
+
+	debug.SetMemoryLimit(maxMemMB * 1024 * 1024)
+	runtime.GOMAXPROCS(maxProcs)
+
+	limits := RuntimeLimits{MaxMemMB: maxMemMB, MaxProcs: maxProcs, PressureMode: mode}
+
+	runtimeLimitsMu.Lock()
+	runtimeLimits = limits
+	runtimeLimitsMu.Unlock()
+
+	return limits
+}
+
+func currentRuntimeLimits() RuntimeLimits {
+	runtimeLimitsMu.Lock()
+	defer runtimeLimitsMu.Unlock()
+	return runtimeLimits
+}
+
+// memoryPressureLevel reads macOS's memory pressure level (0 normal, 1 warn,
+// 2 critical) via sysctl, the memory-side counterpart to the load-average
+// check already used to throttle the scanner.
+func memoryPressureLevel() (int, bool) {
+	output, err := exec.Command("sysctl", "-n", "kern.memorystatus_vm_pressure_level").Output()
+	if err != nil {
+		return 0, false
+	}
+
+	level, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, false
+	}
+	return level, true
+}
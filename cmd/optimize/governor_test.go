@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestSetSelfLimitsDefaults(t *testing.T) {
+	t.Setenv("MOLE_MAX_MEM", "")
+	t.Setenv("MOLE_MAX_PROCS", "")
+	t.Setenv("MOLE_PRESSURE_MODE", "")
+
+	limits := SetSelfLimits()
+	if limits.MaxMemMB != defaultMaxMemMB {
+		t.Errorf("MaxMemMB = %d, want default %d", limits.MaxMemMB, defaultMaxMemMB)
+	}
+	if limits.PressureMode != pressureAdaptive {
+		t.Errorf("PressureMode = %q, want %q", limits.PressureMode, pressureAdaptive)
+	}
+	if limits.MaxProcs < 1 {
+		t.Errorf("MaxProcs = %d, want >= 1", limits.MaxProcs)
+	}
+}
+
+func TestSetSelfLimitsReadsEnv(t *testing.T) {
+	t.Setenv("MOLE_MAX_MEM", "512")
+	t.Setenv("MOLE_MAX_PROCS", "3")
+	t.Setenv("MOLE_PRESSURE_MODE", "strict")
+
+	limits := SetSelfLimits()
+	if limits.MaxMemMB != 512 {
+		t.Errorf("MaxMemMB = %d, want 512", limits.MaxMemMB)
+	}
+	if limits.MaxProcs != 3 {
+		t.Errorf("MaxProcs = %d, want 3", limits.MaxProcs)
+	}
+	if limits.PressureMode != pressureStrict {
+		t.Errorf("PressureMode = %q, want %q", limits.PressureMode, pressureStrict)
+	}
+}
+
+func TestSetSelfLimitsIgnoresGarbageEnv(t *testing.T) {
+	t.Setenv("MOLE_MAX_MEM", "not-a-number")
+	t.Setenv("MOLE_MAX_PROCS", "-5")
+	t.Setenv("MOLE_PRESSURE_MODE", "bogus")
+
+	limits := SetSelfLimits()
+	if limits.MaxMemMB != defaultMaxMemMB {
+		t.Errorf("MaxMemMB = %d, want default %d for garbage input", limits.MaxMemMB, defaultMaxMemMB)
+	}
+	if limits.PressureMode != pressureAdaptive {
+		t.Errorf("PressureMode = %q, want default %q for unrecognized input", limits.PressureMode, pressureAdaptive)
+	}
+}
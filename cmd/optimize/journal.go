@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalEntry records one executed destructive action: the APFS local
+// snapshot taken before it ran, and the paths it touched, so `mole rollback`
+// can restore them later.
+type journalEntry struct {
+	ID         string   `json:"id"`
+	Action     string   `json:"action"`
+	SnapshotID string   `json:"snapshot_id"`
+	Timestamp  int64    `json:"timestamp"`
+	Paths      []string `json:"paths"`
+}
+
+var journalMu sync.Mutex
+
+func journalPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "Application Support", "Mole", "journal.json"), nil
+}
+
+func loadJournal() ([]journalEntry, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing journal: %w", err)
+	}
+	return entries, nil
+}
+
+func appendJournalEntry(entry journalEntry) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+
+	entries, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func findJournalEntry(id string) (*journalEntry, error) {
+	entries, err := loadJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -31,9 +31,23 @@ type SystemHealth struct {
 	DiskUsedPercent float64            `json:"disk_used_percent"`
 	UptimeDays      float64            `json:"uptime_days"`
 	Optimizations   []OptimizationItem `json:"optimizations"`
+	Runtime         RuntimeLimits      `json:"runtime"`
 }
 
 func main() {
+	SetSelfLimits()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			runAction(os.Args[2:])
+			return
+		case "rollback":
+			runRollback(os.Args[2:])
+			return
+		}
+	}
+
 	health := collectSystemHealth()
 
 	encoder := json.NewEncoder(os.Stdout)
@@ -42,11 +56,16 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
 		os.Exit(1)
 	}
+
+	state := getScanState()
+	state.advanceCycle()
+	state.save()
 }
 
 func collectSystemHealth() SystemHealth {
 	health := SystemHealth{
 		Optimizations: []OptimizationItem{},
+		Runtime:       currentRuntimeLimits(),
 	}
 
 	// Collect system info
@@ -63,10 +82,9 @@ func collectSystemHealth() SystemHealth {
 		Safe:        true,
 	})
 
-	// Startup items (conditional)
-	if item := checkStartupItems(); item != nil {
-		health.Optimizations = append(health.Optimizations, *item)
-	}
+	// Persistence mechanisms: LaunchAgents/Daemons, BTM, cron, login hooks,
+	// configuration profiles (conditional, one item per mechanism found)
+	health.Optimizations = append(health.Optimizations, collectPersistenceItems()...)
 
 	// Network services (always show)
 	health.Optimizations = append(health.Optimizations, OptimizationItem{
@@ -109,14 +127,11 @@ func collectSystemHealth() SystemHealth {
 		Safe:        true,
 	})
 
-	// Diagnostic log cleanup (always available)
-	health.Optimizations = append(health.Optimizations, OptimizationItem{
-		Category:    "system",
-		Name:        "Diagnostics Cleanup",
-		Description: "Purge old diagnostic & crash logs",
-		Action:      "log_cleanup",
-		Safe:        true,
-	})
+	// Diagnostics triage: correlate unified log distress signals with crash
+	// reports instead of offering a blanket log purge (conditional, one item
+	// per fault class detected, plus a DiagnosticReports cleanup item when
+	// those reports take up any space)
+	health.Optimizations = append(health.Optimizations, collectDiagnosticsItems()...)
 
 	if item := buildMailDownloadsItem(); item != nil {
 		health.Optimizations = append(health.Optimizations, *item)
@@ -138,10 +153,6 @@ func collectSystemHealth() SystemHealth {
 		health.Optimizations = append(health.Optimizations, *item)
 	}
 
-	if item := buildLoginItemsItem(); item != nil {
-		health.Optimizations = append(health.Optimizations, *item)
-	}
-
 	health.Optimizations = append(health.Optimizations, OptimizationItem{
 		Category:    "system",
 		Name:        "Startup Cache Rebuild",
@@ -275,7 +286,7 @@ func checkStartupItems() *OptimizationItem {
 			suggested = 1
 		}
 		return &OptimizationItem{
-			Category:    "startup",
+			Category:    "persistence",
 			Name:        "Startup Items",
 			Description: fmt.Sprintf("%d items (suggest disable %d)", launchAgentsCount, suggested),
 			Action:      "startup_items",
@@ -389,7 +400,7 @@ func buildLoginItemsItem() *OptimizationItem {
 	}
 
 	return &OptimizationItem{
-		Category:    "startup",
+		Category:    "persistence",
 		Name:        "Login Items",
 		Description: fmt.Sprintf("Review %d login items", len(items)),
 		Action:      "login_items",
@@ -485,6 +496,12 @@ func checkDeveloperCleanup() *OptimizationItem {
 	}
 }
 
+// dirSizeKB returns the apparent size of path in KB. It is backed by a
+// concurrent, cached scanner (see scanner.go) rather than shelling out to
+// `du` per call, so the many cleanup-root lookups in this file run in-process
+// and roots whose mtime hasn't changed are answered from the cache. Each call
+// walks its own root; lookups of different roots are not combined into one
+// scan pass.
 func dirSizeKB(path string) int64 {
 	if path == "" {
 		return 0
@@ -494,23 +511,7 @@ func dirSizeKB(path string) int64 {
 		return 0
 	}
 
-	cmd := exec.Command("du", "-sk", path)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0
-	}
-
-	fields := strings.Fields(string(output))
-	if len(fields) == 0 {
-		return 0
-	}
-
-	size, err := strconv.ParseInt(fields[0], 10, 64)
-	if err != nil {
-		return 0
-	}
-
-	return size
+	return getDirScanner().SizeKB(path)
 }
 
 func formatSizeFromKB(kb int64) string {
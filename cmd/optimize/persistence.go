@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// collectPersistenceItems inventories the launch mechanisms macOS offers for
+// running code automatically: LaunchAgents/LaunchDaemons, the modern
+// BackgroundItems.btm database, cron, periodic scripts, login/logout hooks,
+// and configuration profiles. Each mechanism found is surfaced as its own
+// OptimizationItem under the "persistence" category so a single "Startup
+// Items" entry can no longer hide what's actually running.
+func collectPersistenceItems() []OptimizationItem {
+	checks := []func() *OptimizationItem{
+		checkStartupItems,
+		checkLaunchDaemons,
+		checkBackgroundTaskItems,
+		buildLoginItemsItem,
+		checkUserCrontab,
+		checkPeriodicScripts,
+		checkLoginHooks,
+		checkConfigProfiles,
+	}
+
+	var items []OptimizationItem
+	for _, check := range checks {
+		if item := check(); item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// checkLaunchDaemons inventories /Library/LaunchDaemons and
+// ~/Library/LaunchDaemons. Launchd plist labels are conventionally the
+// reverse-DNS bundle ID the file is named after, so the filename itself
+// (minus the .plist extension) gives us the bundle ID without parsing the
+// plist, the same identifying detail checkBackgroundTaskItems surfaces for
+// BackgroundItems.btm entries.
+func checkLaunchDaemons() *OptimizationItem {
+	daemonDirs := []string{
+		"/Library/LaunchDaemons",
+		filepath.Join(os.Getenv("HOME"), "Library/LaunchDaemons"),
+	}
+
+	var bundleIDs []string
+	count := 0
+	for _, dir := range daemonDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		count += len(entries)
+
+		for _, entry := range entries {
+			label := strings.TrimSuffix(entry.Name(), ".plist")
+			if bundleIDPattern.MatchString(label) {
+				bundleIDs = append(bundleIDs, label)
+			}
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	desc := fmt.Sprintf("%d root-privileged launch daemons", count)
+	if len(bundleIDs) > 0 {
+		sample := bundleIDs
+		if len(sample) > 3 {
+			sample = sample[:3]
+		}
+		desc = fmt.Sprintf("%s (e.g. %s)", desc, strings.Join(sample, ", "))
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Launch Daemons",
+		Description: desc,
+		Action:      "launch_daemons",
+		Safe:        false,
+	}
+}
+
+var bundleIDPattern = regexp.MustCompile(`^[A-Za-z0-9]+(\.[A-Za-z0-9-]+){2,}$`)
+
+// checkBackgroundTaskItems parses the modern BackgroundItems.btm database
+// (the post-Ventura replacement for LaunchAgents as the primary surface users
+// see in System Settings > Login Items) by converting it to JSON via plutil,
+// since there's no bplist decoder in the standard library.
+func checkBackgroundTaskItems() *OptimizationItem {
+	path := "/private/var/db/com.apple.backgroundtaskmanagement/BackgroundItems.btm"
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	cmd := exec.Command("plutil", "-convert", "json", "-o", "-", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil
+	}
+
+	bundleIDs := extractBundleIDs(parsed, nil)
+	if len(bundleIDs) == 0 {
+		return nil
+	}
+
+	sample := bundleIDs
+	if len(sample) > 3 {
+		sample = sample[:3]
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Background Task Management",
+		Description: fmt.Sprintf("%d entries (e.g. %s)", len(bundleIDs), strings.Join(sample, ", ")),
+		Action:      "background_task_items",
+		Safe:        true,
+	}
+}
+
+// extractBundleIDs walks a decoded plist looking for reverse-DNS-shaped
+// strings (e.g. "com.example.updater"), deduplicating as it goes.
+func extractBundleIDs(v interface{}, seen map[string]bool) []string {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+
+	switch val := v.(type) {
+	case string:
+		if bundleIDPattern.MatchString(val) {
+			seen[val] = true
+		}
+	case []interface{}:
+		for _, item := range val {
+			extractBundleIDs(item, seen)
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			extractBundleIDs(item, seen)
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func checkUserCrontab() *OptimizationItem {
+	cmd := exec.Command("crontab", "-l")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		count++
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Cron Jobs",
+		Description: fmt.Sprintf("%d user crontab entries", count),
+		Action:      "cron_jobs",
+		Safe:        true,
+	}
+}
+
+func checkPeriodicScripts() *OptimizationItem {
+	dirs := []string{
+		"/etc/periodic/daily",
+		"/etc/periodic/weekly",
+		"/etc/periodic/monthly",
+		"/etc/cron.d",
+	}
+
+	count := 0
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil {
+			count += len(entries)
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Periodic & Cron.d Scripts",
+		Description: fmt.Sprintf("%d scripts under /etc/periodic and /etc/cron.d", count),
+		Action:      "periodic_scripts",
+		Safe:        true,
+	}
+}
+
+func checkLoginHooks() *OptimizationItem {
+	var hooks []string
+
+	if out, err := exec.Command("defaults", "read", "com.apple.loginwindow", "LoginHook").Output(); err == nil {
+		if hook := strings.TrimSpace(string(out)); hook != "" {
+			hooks = append(hooks, "login: "+hook)
+		}
+	}
+	if out, err := exec.Command("defaults", "read", "com.apple.loginwindow", "LogoutHook").Output(); err == nil {
+		if hook := strings.TrimSpace(string(out)); hook != "" {
+			hooks = append(hooks, "logout: "+hook)
+		}
+	}
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Login/Logout Hooks",
+		Description: strings.Join(hooks, ", "),
+		Action:      "login_hooks",
+		Safe:        false,
+	}
+}
+
+func checkConfigProfiles() *OptimizationItem {
+	if _, err := exec.LookPath("profiles"); err != nil {
+		return nil
+	}
+
+	output, err := exec.Command("profiles", "list").Output()
+	if err != nil {
+		return nil
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.Contains(line, "profileIdentifier:") {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return &OptimizationItem{
+		Category:    "persistence",
+		Name:        "Configuration Profiles",
+		Description: fmt.Sprintf("%d configuration profiles installed", count),
+		Action:      "config_profiles",
+		Safe:        true,
+	}
+}
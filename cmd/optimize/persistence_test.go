@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExtractBundleIDs(t *testing.T) {
+	parsed := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{
+				"identifier": "com.example.updater",
+				"label":      "not-a-bundle-id",
+			},
+			map[string]interface{}{
+				"identifier": "com.apple.finder.sync",
+			},
+		},
+		"duplicate": "com.example.updater",
+	}
+
+	got := extractBundleIDs(parsed, nil)
+	sort.Strings(got)
+
+	want := []string{"com.apple.finder.sync", "com.example.updater"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("extractBundleIDs() = %v, want %v", got, want)
+	}
+}
+
+func TestBundleIDPattern(t *testing.T) {
+	cases := map[string]bool{
+		"com.example.updater":  true,
+		"com.apple.finder":     true,
+		"not-a-bundle-id":      false,
+		"justoneword":          false,
+		"a.b":                  false,
+		"com.example.updater.": false,
+	}
+
+	for input, want := range cases {
+		if got := bundleIDPattern.MatchString(input); got != want {
+			t.Errorf("bundleIDPattern.MatchString(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
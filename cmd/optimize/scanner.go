@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dirCacheEntry is one record in the on-disk directory size cache. ModTime is
+// the top-level directory's mtime at scan time; if it hasn't advanced on the
+// next run we trust the cached size instead of rescanning the subtree.
+type dirCacheEntry struct {
+	SizeKB    int64 `json:"size_kb"`
+	ModTime   int64 `json:"mtime"`
+	ScannedAt int64 `json:"scanned_at"`
+}
+
+// dirScanner is a concurrent replacement for shelling out to `du -sk`. It
+// walks directory trees with a small worker pool and caches results on disk
+// so repeated invocations of mole only rescan subtrees that actually changed.
+type dirScanner struct {
+	mu        sync.Mutex
+	entries   map[string]dirCacheEntry
+	cachePath string
+	dirty     bool
+}
+
+var (
+	dirScannerOnce sync.Once
+	dirScannerInst *dirScanner
+)
+
+// getDirScanner returns the process-wide scanner, loading its on-disk cache
+// on first use.
+func getDirScanner() *dirScanner {
+	dirScannerOnce.Do(func() {
+		dirScannerInst = newDirScanner()
+	})
+	return dirScannerInst
+}
+
+func newDirScanner() *dirScanner {
+	s := &dirScanner{entries: make(map[string]dirCacheEntry)}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		s.cachePath = filepath.Join(home, "Library", "Caches", "Mole", "dir-sizes.json")
+	}
+	s.load()
+
+	return s
+}
+
+func (s *dirScanner) load() {
+	if s.cachePath == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.cachePath)
+	if err != nil {
+		return
+	}
+
+	var entries map[string]dirCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.mu.Unlock()
+}
+
+func (s *dirScanner) save() {
+	if s.cachePath == "" {
+		return
+	}
+
+	s.mu.Lock()
+	dirty := s.dirty
+	entries := make(map[string]dirCacheEntry, len(s.entries))
+	for k, v := range s.entries {
+		entries[k] = v
+	}
+	s.mu.Unlock()
+
+	if !dirty {
+		return
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cachePath), 0o755); err != nil {
+		return
+	}
+
+	_ = os.WriteFile(s.cachePath, data, 0o644)
+}
+
+func (s *dirScanner) getEntry(path string) (dirCacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[path]
+	return entry, ok
+}
+
+func (s *dirScanner) setEntry(path string, entry dirCacheEntry) {
+	s.mu.Lock()
+	s.entries[path] = entry
+	s.dirty = true
+	s.mu.Unlock()
+}
+
+// SizeKB returns the apparent size of path in KB. If the root itself hasn't
+// changed since the last scan, the cached total is reused outright;
+// otherwise scanRootWithBloomGate (bloomscan.go) recomputes it, reusing
+// cached sizes for any immediate subdirectory the bloom/rotation state says
+// hasn't changed and doesn't need a rescan this cycle.
+func (s *dirScanner) SizeKB(path string) int64 {
+	canonical, err := filepath.Abs(path)
+	if err != nil {
+		canonical = path
+	}
+
+	info, err := os.Stat(canonical)
+	if err != nil {
+		return 0
+	}
+	topMtime := info.ModTime().Unix()
+
+	cached, ok := s.getEntry(canonical)
+	if ok && cached.ModTime == topMtime && !getScanState().forceFullScan {
+		return cached.SizeKB
+	}
+
+	sizeKB := scanRootWithBloomGate(canonical)
+
+	s.setEntry(canonical, dirCacheEntry{
+		SizeKB:    sizeKB,
+		ModTime:   topMtime,
+		ScannedAt: time.Now().Unix(),
+	})
+	s.save()
+
+	return sizeKB
+}
+
+// pathQueue is an unbounded work queue of directories still to scan. Unlike a
+// fixed-capacity channel, push never blocks, so a worker that is simultaneously
+// draining the queue (consumer) and discovering new subdirectories (producer)
+// can never deadlock against its own siblings doing the same thing — which a
+// bounded channel can, once every worker is blocked trying to submit children
+// with the buffer full and nobody left to drain it.
+type pathQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []string
+	pending int64
+	closed  bool
+}
+
+func newPathQueue() *pathQueue {
+	q := &pathQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push enqueues a directory and counts it as outstanding work. Never blocks.
+func (q *pathQueue) push(path string) {
+	q.mu.Lock()
+	q.items = append(q.items, path)
+	q.pending++
+	q.cond.Signal()
+	q.mu.Unlock()
+}
+
+// pop blocks until a directory is available or the queue has closed because
+// no work remains outstanding.
+func (q *pathQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return "", false
+	}
+
+	last := len(q.items) - 1
+	path := q.items[last]
+	q.items = q.items[:last]
+	return path, true
+}
+
+// done marks one directory as finished. Once no directory is queued or still
+// being processed, the queue closes and wakes every worker blocked in pop.
+func (q *pathQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	if q.pending == 0 {
+		q.closed = true
+		q.cond.Broadcast()
+	}
+	q.mu.Unlock()
+}
+
+// scanTreeKB walks root with a worker pool pulling directories off an
+// unbounded queue, summing apparent file sizes via os.Lstat. Worker count and
+// per-directory pacing adapt to system load so the scan doesn't itself become
+// a source of slowdown.
+func scanTreeKB(root string) int64 {
+	workers := adaptiveWorkerCount()
+
+	queue := newPathQueue()
+	var total int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := queue.pop()
+				if !ok {
+					return
+				}
+
+				if isUnderLoad() {
+					time.Sleep(5 * time.Millisecond)
+				}
+				own := scanOneDir(dir, queue.push)
+				atomic.AddInt64(&total, own)
+				queue.done()
+			}
+		}()
+	}
+
+	queue.push(root)
+	wg.Wait()
+
+	return total / 1024
+}
+
+// scanOneDir reads a single directory's entries, summing the apparent size of
+// its regular files/symlinks and re-submitting any subdirectories as new
+// jobs. It returns the size (bytes) owned directly by dir.
+func scanOneDir(dir string, submit func(string)) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		full := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			submit(full)
+			continue
+		}
+
+		info, err := os.Lstat(full)
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+	}
+
+	return size
+}
+
+// adaptiveWorkerCount picks a starting worker pool size based on CPU count,
+// halved when the machine is already under load.
+func adaptiveWorkerCount() int {
+	workers := runtime.NumCPU() * 2
+	if workers < 2 {
+		workers = 2
+	}
+	if isUnderLoad() {
+		workers /= 2
+		if workers < 1 {
+			workers = 1
+		}
+	}
+	return workers
+}
+
+// isUnderLoad reports whether the scanner should throttle itself, combining
+// CPU load average with memory pressure. Thresholds tighten under
+// MOLE_PRESSURE_MODE=strict and the check is skipped entirely under "off".
+func isUnderLoad() bool {
+	mode := currentRuntimeLimits().PressureMode
+	if mode == pressureOff {
+		return false
+	}
+
+	loadThreshold := float64(runtime.NumCPU())
+	minPressureLevel := 2 // critical
+	if mode == pressureStrict {
+		loadThreshold /= 2
+		minPressureLevel = 1 // warn
+	}
+
+	if load, ok := getLoadAvg(); ok && load > loadThreshold {
+		return true
+	}
+	if level, ok := memoryPressureLevel(); ok && level >= minPressureLevel {
+		return true
+	}
+	return false
+}
+
+func getLoadAvg() (float64, bool) {
+	cmd := exec.Command("sysctl", "-n", "vm.loadavg")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, false
+	}
+
+	// Output looks like "{ 1.23 1.45 1.60 }"; the first figure is the 1-minute
+	// load average.
+	fields := strings.Fields(string(output))
+	for _, f := range fields {
+		if f == "{" || f == "}" {
+			continue
+		}
+		load, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			continue
+		}
+		return load, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPathQueueDrainsAllPushedWork is the regression test for the bounded
+// channel deadlock described on pathQueue: once the roots are queued, workers
+// that are simultaneously the sole producers and sole
+// consumers of the queue must never block on push, and pop must unblock once
+// every outstanding item has been completed via done.
+func TestPathQueueDrainsAllPushedWork(t *testing.T) {
+	q := newPathQueue()
+
+	const roots = 16
+	const fanout = 50
+
+	var popped int64
+	var mu sync.Mutex
+
+	for i := 0; i < roots; i++ {
+		q.push("root")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				path, ok := q.pop()
+				if !ok {
+					return
+				}
+
+				mu.Lock()
+				popped++
+				mu.Unlock()
+
+				// Simulate scanOneDir discovering a wide fan-out of sibling
+				// directories before it finishes its own work — the exact
+				// pattern that wedged the old bounded channel.
+				if path == "root" {
+					for j := 0; j < fanout; j++ {
+						q.push("child")
+					}
+				}
+				q.done()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pathQueue deadlocked: workers never drained all pushed work")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := int64(roots + roots*fanout); popped != want {
+		t.Fatalf("popped %d items, want %d", popped, want)
+	}
+}
+
+func TestPathQueuePopReturnsFalseOnceDrained(t *testing.T) {
+	q := newPathQueue()
+	q.push("only")
+
+	path, ok := q.pop()
+	if !ok || path != "only" {
+		t.Fatalf("pop() = (%q, %v), want (%q, true)", path, ok, "only")
+	}
+	q.done()
+
+	if _, ok := q.pop(); ok {
+		t.Fatal("pop() after queue closed = true, want false")
+	}
+}